@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// updateWebhookURL receives {db, old_build_epoch, new_build_epoch, result} after
+// every hardenedUpdate cycle so ops pipelines can react to reload outcomes
+var updateWebhookURL = os.Getenv("IPQUERY_UPDATE_WEBHOOK")
+
+// adminToken gates POST /admin/*. Unset disables the admin surface entirely.
+var adminToken = os.Getenv("IPQUERY_ADMIN_TOKEN")
+
+// requireAdminToken checks the "Authorization: Bearer <token>" header against
+// adminToken, writing a 401/503 response itself when the check fails
+func requireAdminToken(writer http.ResponseWriter, request *http.Request) bool {
+	if adminToken == "" {
+		writer.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = writer.Write([]byte(`{"error": "admin endpoints disabled: IPQUERY_ADMIN_TOKEN not set"}`))
+		return false
+	}
+
+	provided := strings.TrimPrefix(request.Header.Get("Authorization"), "Bearer ")
+	if provided == "" || provided != adminToken {
+		writer.WriteHeader(http.StatusUnauthorized)
+		_, _ = writer.Write([]byte(`{"error": "invalid or missing admin token"}`))
+		return false
+	}
+	return true
+}
+
+// adminReloadHandler serves POST /admin/reload, triggering an out-of-schedule
+// update of all configured database sources
+func adminReloadHandler(writer http.ResponseWriter, request *http.Request) {
+	writer.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if request.Method != http.MethodPost {
+		writer.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdminToken(writer, request) {
+		return
+	}
+
+	appLogger.Infof("Admin-triggered reload requested\n")
+	if !updateDatabases() {
+		writer.WriteHeader(http.StatusConflict)
+		_ = json.NewEncoder(writer).Encode(map[string]string{"error": "an update cycle is already in progress, try again shortly"})
+		return
+	}
+
+	writer.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(writer).Encode(map[string]string{"status": "reload triggered"})
+}
+
+// adminRollbackHandler serves POST /admin/rollback?db=city|asn, reopening that
+// database's .mmdb.bak (from the previous successful update cycle) and swapping
+// it back in under databaseMutex
+func adminRollbackHandler(writer http.ResponseWriter, request *http.Request) {
+	writer.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if request.Method != http.MethodPost {
+		writer.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdminToken(writer, request) {
+		return
+	}
+
+	db := request.URL.Query().Get("db")
+	var spec hardenedUpdateSpec
+	switch db {
+	case "city":
+		spec = cityUpdateSpec()
+	case "asn":
+		spec = asnUpdateSpec()
+	default:
+		writer.WriteHeader(http.StatusBadRequest)
+		_, _ = writer.Write([]byte(`{"error": "db must be 'city' or 'asn'"}`))
+		return
+	}
+
+	if err := rollbackDB(spec); err != nil {
+		writer.WriteHeader(http.StatusInternalServerError)
+		_ = json.NewEncoder(writer).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	appLogger.Infof("Rolled back %s database to previous backup\n", db)
+	writer.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(writer).Encode(map[string]string{"status": "rolled back", "db": db})
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// 单次 /batch 请求最多允许的 IP 数量，可通过 -batch-limit 启动参数调整
+var batchLimitFlag = flag.Int("batch-limit", 1000, "maximum number of IPs accepted per /batch request")
+var batchLimit = 1000
+
+// allowCIDRExpansion 控制 CIDR 条目的处理方式：
+// false（默认）时该条目视为无效，结果中仅回显原始字符串，其余条目照常查询；
+// true 时把 CIDR 折叠成网络地址做一次代表性查询
+var allowCIDRExpansion = false
+
+type batchRequest struct {
+	IPs  []string `json:"ips"`
+	Lang string   `json:"lang"`
+}
+
+// batchHandler 处理 POST /batch，按输入顺序返回 FlatResponse 数组
+// 内部用一个按 GOMAXPROCS 定长的 worker pool 并发查询，每个 worker 通过
+// provider.Lookup 独立持有短暂的 databaseMutex 读锁，不在外层重复加锁
+func batchHandler(writer http.ResponseWriter, request *http.Request) {
+	writer.Header().Set("Access-Control-Allow-Origin", "*")
+	writer.Header().Set("Content-Type", "application/json; charset=utf-8")
+
+	if request.Method != http.MethodPost {
+		writer.WriteHeader(http.StatusMethodNotAllowed)
+		_, _ = writer.Write([]byte(`{"error": "method not allowed"}`))
+		return
+	}
+
+	var reqBody batchRequest
+	if err := json.NewDecoder(request.Body).Decode(&reqBody); err != nil {
+		writer.WriteHeader(http.StatusBadRequest)
+		_, _ = writer.Write([]byte(`{"error": "invalid request body"}`))
+		return
+	}
+
+	if len(reqBody.IPs) == 0 {
+		writer.WriteHeader(http.StatusBadRequest)
+		_, _ = writer.Write([]byte(`{"error": "ips must not be empty"}`))
+		return
+	}
+	if len(reqBody.IPs) > batchLimit {
+		writer.WriteHeader(http.StatusBadRequest)
+		_, _ = writer.Write([]byte(fmt.Sprintf(`{"error": "too many ips, max %d per request"}`, batchLimit)))
+		return
+	}
+
+	targetLang := resolveLang(reqBody.Lang)
+	provider := buildProviderChain(strings.ToLower(request.URL.Query().Get("source")))
+
+	targets := make([]net.IP, len(reqBody.IPs))
+	for i, raw := range reqBody.IPs {
+		targets[i] = parseBatchEntry(raw)
+	}
+
+	// 不在这里取外层 databaseMutex.RLock()：每个 worker 调用的 provider.Lookup
+	// 内部已经各自短暂 RLock 一次。sync.RWMutex 会在有写者排队时阻塞新来的 RLock，
+	// 外层锁持有期间若 hardenedUpdate/rollbackDB 的 Lock() 插队等待，会把所有
+	// worker 的内层 RLock 一起卡住，而 batchHandler 又在等这些 worker 完成——
+	// 形成死锁。让 provider.Lookup 各自独立加锁即可，没有必要在外层重复持有
+	streamBatchResults(writer, targets, reqBody.IPs, provider, targetLang)
+}
+
+// indexedResult 把一个 worker 的查询结果和它在原始请求中的下标绑在一起，
+// 好让 streamBatchResults 按原始顺序重新排列乱序完成的结果
+type indexedResult struct {
+	idx  int
+	resp *FlatResponse
+}
+
+// runBatchWorkers 用 GOMAXPROCS 个 worker 并发消费任务队列，每个结果算出来就
+// 立刻送进返回的 channel（不等其它 worker），channel 在所有任务完成后关闭
+func runBatchWorkers(targets []net.IP, raw []string, provider LookupProvider, lang string) <-chan indexedResult {
+	workerCount := runtime.GOMAXPROCS(0)
+	if workerCount > len(targets) {
+		workerCount = len(targets)
+	}
+
+	jobs := make(chan int)
+	out := make(chan indexedResult)
+	var wg sync.WaitGroup
+
+	for i := 0; i < workerCount; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range jobs {
+				ip := targets[idx]
+				if ip == nil {
+					out <- indexedResult{idx, &FlatResponse{IP: raw[idx]}}
+					continue
+				}
+				resp, err := provider.Lookup(ip, lang)
+				if err != nil {
+					out <- indexedResult{idx, &FlatResponse{IP: ip.String()}}
+					continue
+				}
+				markLookupSucceeded()
+				out <- indexedResult{idx, resp}
+			}
+		}()
+	}
+
+	go func() {
+		for i := range targets {
+			jobs <- i
+		}
+		close(jobs)
+		wg.Wait()
+		close(out)
+	}()
+
+	return out
+}
+
+// parseBatchEntry 解析单个批量条目，支持裸 IP 和（当 allowCIDRExpansion 开启时）CIDR。
+// 返回 nil 表示该条目无效，结果中仅回显原始字符串
+func parseBatchEntry(raw string) net.IP {
+	if strings.Contains(raw, "/") {
+		if !allowCIDRExpansion {
+			return nil
+		}
+		_, ipNet, err := net.ParseCIDR(raw)
+		if err != nil {
+			return nil
+		}
+		return ipNet.IP
+	}
+	return net.ParseIP(raw)
+}
+
+// streamBatchResults 跑 worker pool 并用 json.Encoder 逐条写出结果数组：每个
+// worker 算完的结果先进一个乱序到达的小缓冲区，一旦凑齐了下一个原始下标就立刻
+// 写出并 Flush，不必等整批算完，内存占用只取决于乱序到达的窗口而不是整批大小
+func streamBatchResults(writer http.ResponseWriter, targets []net.IP, raw []string, provider LookupProvider, lang string) {
+	encoder := json.NewEncoder(writer)
+	flusher, _ := writer.(http.Flusher)
+
+	out := runBatchWorkers(targets, raw, provider, lang)
+
+	_, _ = writer.Write([]byte("["))
+	pending := make(map[int]*FlatResponse)
+	next := 0
+	wroteFirst := false
+	for r := range out {
+		pending[r.idx] = r.resp
+		for {
+			resp, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			if wroteFirst {
+				_, _ = writer.Write([]byte(","))
+			}
+			wroteFirst = true
+			_ = encoder.Encode(resp)
+			if flusher != nil {
+				flusher.Flush()
+			}
+			next++
+		}
+	}
+	_, _ = writer.Write([]byte("]"))
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/honego/ipquery/pkg/observability"
+)
+
+// Config 是 -config 指向的 YAML 配置文件结构，所有字段都是可选的——
+// 命令行 flag 和环境变量的默认值在没有配置文件时依然生效
+type Config struct {
+	ListenAddr string `yaml:"listen_addr"`
+	UpdateCron string `yaml:"update_cron"`
+	LogLevel   string `yaml:"log_level"`
+	DBDir      string `yaml:"db_dir"`
+	BatchLimit int    `yaml:"batch_limit"`
+	Sources    struct {
+		CityURL string `yaml:"city_url"`
+		AsnURL  string `yaml:"asn_url"`
+	} `yaml:"sources"`
+}
+
+var (
+	configPath = flag.String("config", "", "path to a YAML config file (listen address, update cron, db sources, log level)")
+	listenAddr = ":8080"
+	logLevel   = "info"
+)
+
+// loadConfig 读取 -config 指定的文件；未指定时返回空配置，文件不存在或解析失败时返回错误
+func loadConfig(path string) (*Config, error) {
+	if path == "" {
+		return &Config{}, nil
+	}
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var cfg Config
+	if err := yaml.Unmarshal(raw, &cfg); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// applyConfig 把加载到的配置层层叠加到运行时用到的全局变量上
+func applyConfig(cfg *Config) {
+	if cfg.ListenAddr != "" {
+		listenAddr = cfg.ListenAddr
+	}
+	if cfg.LogLevel != "" {
+		logLevel = cfg.LogLevel
+		appLogger = observability.NewLogger(logLevel)
+	}
+	if cfg.UpdateCron != "" {
+		updateCronExpr = cfg.UpdateCron
+	}
+	if cfg.DBDir != "" {
+		dbDir = cfg.DBDir
+	}
+	if cfg.Sources.CityURL != "" {
+		cityDownloadURL = cfg.Sources.CityURL
+	}
+	if cfg.Sources.AsnURL != "" {
+		asnDownloadURL = cfg.Sources.AsnURL
+	}
+	if cfg.BatchLimit > 0 {
+		batchLimit = cfg.BatchLimit
+	}
+}
+
+// resolveDBDir 按优先级决定数据库目录：
+//  1. IPQUERY_DB_DIR（显式指定，优先级最高）
+//  2. $IPQUERY_HOME/db
+//  3. $XDG_DATA_HOME/ipquery（Linux/macOS）或 %LOCALAPPDATA%\ipquery（Windows）
+//  4. 兜底回退到 ./db，兼容旧的部署方式
+func resolveDBDir() string {
+	if dir := os.Getenv("IPQUERY_DB_DIR"); dir != "" {
+		return dir
+	}
+	if home := os.Getenv("IPQUERY_HOME"); home != "" {
+		return filepath.Join(home, "db")
+	}
+
+	if runtime.GOOS == "windows" {
+		if localAppData := os.Getenv("LOCALAPPDATA"); localAppData != "" {
+			return filepath.Join(localAppData, "ipquery")
+		}
+		return "./db"
+	}
+
+	xdgDataHome := os.Getenv("XDG_DATA_HOME")
+	if xdgDataHome == "" {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			appLogger.Infof("Could not resolve home directory, falling back to ./db: %v\n", err)
+			return "./db"
+		}
+		xdgDataHome = filepath.Join(homeDir, ".local", "share")
+	}
+	return filepath.Join(xdgDataHome, "ipquery")
+}
+
+// envOrDefault 是读取 IPQUERY_* 镜像地址覆盖时的小工具函数
+func envOrDefault(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+const earthRadiusKM = 6371.0
+const kmPerMile = 1.609344
+
+// haversineKM returns the great-circle distance between two lat/lon points in kilometers
+func haversineKM(lat1, lon1, lat2, lon2 float64) float64 {
+	toRad := func(deg float64) float64 { return deg * math.Pi / 180 }
+
+	dLat := toRad(lat2 - lat1)
+	dLon := toRad(lon2 - lon1)
+
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(toRad(lat1))*math.Cos(toRad(lat2))*math.Sin(dLon/2)*math.Sin(dLon/2)
+	c := 2 * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
+
+	return earthRadiusKM * c
+}
+
+// distanceResponse is the payload for GET /distance
+type distanceResponse struct {
+	From          *FlatResponse `json:"from"`
+	To            *FlatResponse `json:"to"`
+	DistanceKM    float64       `json:"distance_km"`
+	DistanceMiles float64       `json:"distance_miles"`
+}
+
+// distanceHandler serves GET /distance?from=<ip>&to=<ip>, returning the
+// haversine distance between the two IPs' cached geo coordinates
+func distanceHandler(writer http.ResponseWriter, request *http.Request) {
+	writer.Header().Set("Access-Control-Allow-Origin", "*")
+	writer.Header().Set("Content-Type", "application/json; charset=utf-8")
+
+	fromIP := net.ParseIP(request.URL.Query().Get("from"))
+	toIP := net.ParseIP(request.URL.Query().Get("to"))
+	if fromIP == nil || toIP == nil {
+		writer.WriteHeader(http.StatusBadRequest)
+		_, _ = writer.Write([]byte(`{"error": "from and to must both be valid IPs"}`))
+		return
+	}
+
+	targetLang := resolveLang(request.URL.Query().Get("lang"))
+	provider := buildProviderChain(strings.ToLower(request.URL.Query().Get("source")))
+
+	fromResp, err := provider.Lookup(fromIP, targetLang)
+	if err != nil || fromResp.Latitude == nil || fromResp.Longitude == nil {
+		writer.WriteHeader(http.StatusUnprocessableEntity)
+		_, _ = writer.Write([]byte(`{"error": "no cached coordinates for 'from' IP"}`))
+		return
+	}
+	toResp, err := provider.Lookup(toIP, targetLang)
+	if err != nil || toResp.Latitude == nil || toResp.Longitude == nil {
+		writer.WriteHeader(http.StatusUnprocessableEntity)
+		_, _ = writer.Write([]byte(`{"error": "no cached coordinates for 'to' IP"}`))
+		return
+	}
+
+	distanceKM := haversineKM(*fromResp.Latitude, *fromResp.Longitude, *toResp.Latitude, *toResp.Longitude)
+
+	encoder := json.NewEncoder(writer)
+	encoder.SetIndent("", "  ")
+	_ = encoder.Encode(distanceResponse{
+		From:          fromResp,
+		To:            toResp,
+		DistanceKM:    round2(distanceKM),
+		DistanceMiles: round2(distanceKM / kmPerMile),
+	})
+}
+
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+// nearbySample is one ASN-representative point built from the MaxMind tree at startup
+type nearbySample struct {
+	IP             net.IP
+	ASN            uint
+	Org            string
+	Latitude       float64
+	Longitude      float64
+	AccuracyRadius uint16
+}
+
+// nearbySampleCap bounds how many representative points buildNearbySamples keeps,
+// so startup stays fast even against a City DB with millions of networks;
+// override with IPQUERY_NEARBY_SAMPLE_CAP for larger deployments
+var nearbySampleCap = func() int {
+	if raw := os.Getenv("IPQUERY_NEARBY_SAMPLE_CAP"); raw != "" {
+		if n, err := strconv.Atoi(raw); err == nil && n > 0 {
+			return n
+		}
+	}
+	return 20000
+}()
+
+var nearbySamples []nearbySample
+
+// buildNearbySamples walks the City DB's network tree once at startup and keeps one
+// geo-tagged representative IP per ASN, used to serve GET /nearby without a live
+// per-request tree walk
+func buildNearbySamples() {
+	if cityDatabase == nil {
+		return
+	}
+
+	networks := cityDatabase.Networks(maxminddb.SkipAliasedNetworks)
+	seenASN := make(map[uint]bool)
+
+	for networks.Next() && len(nearbySamples) < nearbySampleCap {
+		var record CityRecord
+		network, err := networks.Network(&record)
+		if err != nil {
+			continue
+		}
+		if record.Location.Latitude == 0 && record.Location.Longitude == 0 {
+			continue
+		}
+
+		representativeIP := network.IP
+		var asnRecord ASNRecord
+		if asnDatabase != nil {
+			_ = asnDatabase.Lookup(representativeIP, &asnRecord)
+		}
+		if asnRecord.AutonomousSystemNumber == 0 || seenASN[asnRecord.AutonomousSystemNumber] {
+			continue
+		}
+		seenASN[asnRecord.AutonomousSystemNumber] = true
+
+		nearbySamples = append(nearbySamples, nearbySample{
+			IP:             representativeIP,
+			ASN:            asnRecord.AutonomousSystemNumber,
+			Org:            asnRecord.AutonomousSystemOrganization,
+			Latitude:       record.Location.Latitude,
+			Longitude:      record.Location.Longitude,
+			AccuracyRadius: record.Location.AccuracyRadius,
+		})
+	}
+
+	appLogger.Infof("Built %d ASN-representative geo samples for /nearby\n", len(nearbySamples))
+}
+
+// nearbyHit is one entry in the GET /nearby response
+type nearbyHit struct {
+	IP             string  `json:"ip"`
+	ASN            uint    `json:"asn"`
+	Org            string  `json:"org,omitempty"`
+	DistanceKM     float64 `json:"distance_km"`
+	AccuracyRadius uint16  `json:"accuracy_radius,omitempty"`
+}
+
+// nearbyHandler serves GET /nearby?ip=<ip>&radius=100km&sample=asn, returning the
+// precomputed ASN-representative samples within the requested radius of ip
+func nearbyHandler(writer http.ResponseWriter, request *http.Request) {
+	writer.Header().Set("Access-Control-Allow-Origin", "*")
+	writer.Header().Set("Content-Type", "application/json; charset=utf-8")
+
+	query := request.URL.Query()
+
+	if sample := query.Get("sample"); sample != "" && sample != "asn" {
+		writer.WriteHeader(http.StatusBadRequest)
+		_, _ = writer.Write([]byte(`{"error": "sample must be 'asn'"}`))
+		return
+	}
+
+	ip := net.ParseIP(query.Get("ip"))
+	if ip == nil {
+		writer.WriteHeader(http.StatusBadRequest)
+		_, _ = writer.Write([]byte(`{"error": "ip is required and must be valid"}`))
+		return
+	}
+
+	radiusKM, err := parseRadius(query.Get("radius"))
+	if err != nil {
+		writer.WriteHeader(http.StatusBadRequest)
+		_, _ = writer.Write([]byte(fmt.Sprintf(`{"error": %q}`, err.Error())))
+		return
+	}
+
+	targetLang := resolveLang(query.Get("lang"))
+	resp, err := defaultChain.Lookup(ip, targetLang)
+	if err != nil || resp.Latitude == nil || resp.Longitude == nil {
+		writer.WriteHeader(http.StatusUnprocessableEntity)
+		_, _ = writer.Write([]byte(`{"error": "no cached coordinates for ip"}`))
+		return
+	}
+
+	var hits []nearbyHit
+	for _, candidate := range nearbySamples {
+		distance := haversineKM(*resp.Latitude, *resp.Longitude, candidate.Latitude, candidate.Longitude)
+		if distance <= radiusKM {
+			hits = append(hits, nearbyHit{
+				IP:             candidate.IP.String(),
+				ASN:            candidate.ASN,
+				Org:            candidate.Org,
+				DistanceKM:     round2(distance),
+				AccuracyRadius: candidate.AccuracyRadius,
+			})
+		}
+	}
+
+	encoder := json.NewEncoder(writer)
+	encoder.SetIndent("", "  ")
+	_ = encoder.Encode(hits)
+}
+
+// parseRadius accepts a plain number (kilometers) or a number suffixed with
+// "km" or "mi", e.g. "100km", "50mi", and always returns kilometers
+func parseRadius(raw string) (float64, error) {
+	if raw == "" {
+		return 0, fmt.Errorf("radius is required")
+	}
+
+	raw = strings.ToLower(strings.TrimSpace(raw))
+	unit := "km"
+	numeric := raw
+	switch {
+	case strings.HasSuffix(raw, "km"):
+		numeric = strings.TrimSuffix(raw, "km")
+	case strings.HasSuffix(raw, "mi"):
+		numeric = strings.TrimSuffix(raw, "mi")
+		unit = "mi"
+	}
+
+	value, err := strconv.ParseFloat(numeric, 64)
+	if err != nil || value <= 0 {
+		return 0, fmt.Errorf("invalid radius %q", raw)
+	}
+
+	if unit == "mi" {
+		return value * kmPerMile, nil
+	}
+	return value, nil
+}
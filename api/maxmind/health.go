@@ -0,0 +1,65 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+)
+
+// maxDBAge is how stale the on-disk City/ASN files may get before /healthz fails
+const maxDBAge = 30 * 24 * time.Hour
+
+// hadSuccessfulLookup flips to 1 the first time ipHandler or batchHandler resolves an IP,
+// and gates /readyz — a process that's up but has never completed a real lookup
+// (e.g. dependencies still warming) shouldn't receive traffic yet
+var hadSuccessfulLookup int32
+
+func markLookupSucceeded() {
+	atomic.StoreInt32(&hadSuccessfulLookup, 1)
+}
+
+// healthzHandler reports 200 iff both MaxMind handles are loaded and the
+// on-disk database files aren't older than maxDBAge
+func healthzHandler(writer http.ResponseWriter, _ *http.Request) {
+	databaseMutex.RLock()
+	loaded := cityDatabase != nil && asnDatabase != nil
+	databaseMutex.RUnlock()
+
+	if !loaded {
+		writer.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = writer.Write([]byte(`{"status": "unhealthy", "reason": "database not loaded"}`))
+		return
+	}
+
+	for _, name := range []string{"City.mmdb", "ASN.mmdb"} {
+		if age, err := dbFileAge(filepath.Join(dbDir, name)); err != nil || age > maxDBAge {
+			writer.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = writer.Write([]byte(`{"status": "unhealthy", "reason": "database stale"}`))
+			return
+		}
+	}
+
+	writer.WriteHeader(http.StatusOK)
+	_, _ = writer.Write([]byte(`{"status": "healthy"}`))
+}
+
+// readyzHandler reports 200 iff at least one lookup has completed since start
+func readyzHandler(writer http.ResponseWriter, _ *http.Request) {
+	if atomic.LoadInt32(&hadSuccessfulLookup) == 0 {
+		writer.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = writer.Write([]byte(`{"status": "not ready"}`))
+		return
+	}
+	writer.WriteHeader(http.StatusOK)
+	_, _ = writer.Write([]byte(`{"status": "ready"}`))
+}
+
+func dbFileAge(path string) (time.Duration, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+	return time.Since(info.ModTime()), nil
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+)
+
+// IP2RegionProvider 解析 ip2region xdb v2 格式数据库，仅支持 IPv4
+//
+// xdb v2 布局：
+//   - 256 字节 header（含 version、索引策略等，此处仅关心后续的向量索引）
+//   - 向量索引区：固定 256*256 个条目，每条目 4+4+4 = 12 字节
+//     （segment 起始指针、segment 结束指针、segment 数据区起始偏移）
+//     按 IP 的第一、第二字节定位到一个条目，将搜索范围从整段索引收窄到一小块
+//   - segment 索引区：每条目 14 字节 = 4(起始IP) + 4(结束IP) + 2(region 长度) + 4(region 偏移)
+//   - region 数据区：UTF-8 文本，字段之间用 '|' 分隔，通常是 国家|区域|省份|城市|ISP
+type IP2RegionProvider struct {
+	mu   sync.RWMutex
+	data []byte
+}
+
+const (
+	ip2rHeaderLen       = 256
+	ip2rVectorIndexRows = 256
+	ip2rVectorIndexCols = 256
+	ip2rVectorIndexSize = 12
+	ip2rSegIndexSize    = 14
+)
+
+func NewIP2RegionProvider(path string) (*IP2RegionProvider, error) {
+	p := &IP2RegionProvider{}
+	if err := p.reload(path); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *IP2RegionProvider) Name() string { return "ip2region" }
+
+func (p *IP2RegionProvider) reload(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("ip2region: %w", err)
+	}
+	if len(data) < ip2rHeaderLen+ip2rVectorIndexRows*ip2rVectorIndexCols*ip2rVectorIndexSize {
+		return fmt.Errorf("ip2region: file %s too small for xdb v2 vector index", path)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.data = data
+	return nil
+}
+
+func (p *IP2RegionProvider) Lookup(ip net.IP, lang string) (*FlatResponse, error) {
+	v4 := ip.To4()
+	if v4 == nil {
+		return nil, fmt.Errorf("ip2region: ipv6 not supported")
+	}
+	target := binary.BigEndian.Uint32(v4)
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	region, err := p.search(target)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &FlatResponse{IP: ip.String()}
+	applyIP2RegionFields(resp, region)
+	return resp, nil
+}
+
+// search 实现 xdb v2 的两级查找：256x256 向量索引 -> segment 区间二分查找
+func (p *IP2RegionProvider) search(ip uint32) (string, error) {
+	// 第一级：用 IP 的最高两个字节直接定位到向量索引里的一个 segment 区间
+	row := (ip >> 24) & 0xFF
+	col := (ip >> 16) & 0xFF
+	vectorOffset := ip2rHeaderLen + (row*ip2rVectorIndexCols+col)*ip2rVectorIndexSize
+
+	if int(vectorOffset+ip2rVectorIndexSize) > len(p.data) {
+		return "", fmt.Errorf("ip2region: vector index out of range")
+	}
+
+	segStart := binary.LittleEndian.Uint32(p.data[vectorOffset : vectorOffset+4])
+	segEnd := binary.LittleEndian.Uint32(p.data[vectorOffset+4 : vectorOffset+8])
+
+	if segEnd <= segStart {
+		return "", fmt.Errorf("ip2region: ip not found")
+	}
+
+	// 第二级：在 [segStart, segEnd) 范围内的 segment 索引条目中做二分查找
+	low := uint32(0)
+	high := (segEnd - segStart) / ip2rSegIndexSize
+
+	for low < high {
+		mid := low + (high-low)/2
+		entry := segStart + mid*ip2rSegIndexSize
+		if int(entry+ip2rSegIndexSize) > len(p.data) {
+			return "", fmt.Errorf("ip2region: segment index out of range")
+		}
+
+		startIP := binary.LittleEndian.Uint32(p.data[entry : entry+4])
+		endIP := binary.LittleEndian.Uint32(p.data[entry+4 : entry+8])
+
+		switch {
+		case ip < startIP:
+			high = mid
+		case ip > endIP:
+			low = mid + 1
+		default:
+			regionLen := binary.LittleEndian.Uint16(p.data[entry+8 : entry+10])
+			regionOffset := binary.LittleEndian.Uint32(p.data[entry+10 : entry+14])
+			if int(regionOffset+uint32(regionLen)) > len(p.data) {
+				return "", fmt.Errorf("ip2region: region data out of range")
+			}
+			return string(p.data[regionOffset : regionOffset+uint32(regionLen)]), nil
+		}
+	}
+
+	return "", fmt.Errorf("ip2region: ip not found")
+}
+
+// applyIP2RegionFields 把 "国家|区域|省份|城市|ISP" 格式的 region 字符串铺到响应字段上
+func applyIP2RegionFields(resp *FlatResponse, region string) {
+	parts := strings.SplitN(region, "|", 5)
+	get := func(i int) string {
+		if i < len(parts) && parts[i] != "0" {
+			return parts[i]
+		}
+		return ""
+	}
+
+	resp.Country = get(0)
+	resp.Region = get(2)
+	resp.City = get(3)
+	if isp := get(4); isp != "" {
+		resp.Org = isp
+	}
+}
@@ -1,18 +1,32 @@
 package main
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"flag"
+	"fmt"
 	"io"
-	"log"
 	"net"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/oschwald/maxminddb-golang"
+
+	"github.com/honego/ipquery/pkg/observability"
+)
+
+// appLogger/metrics 是跨文件共享的可观测性句柄，由 flag.Parse()/loadConfig() 决定的
+// logLevel 在 main() 真正运行前就需要用到（resolveDBDir 里的回退日志），所以在此处即初始化
+var (
+	appLogger = observability.NewLogger(logLevel)
+	metrics   = observability.NewMetrics()
 )
 
 // MaxMind 数据库解析结构
@@ -79,13 +93,21 @@ var (
 	asnDatabase   *maxminddb.Reader
 	timeZoneCache sync.Map     // 时区缓存
 	databaseMutex sync.RWMutex // 读写锁
-	dbDir         = "./db"
+	dbDir         = resolveDBDir()
+
+	cityDownloadURL = envOrDefault("IPQUERY_CITY_URL", "https://github.com/xjasonlyu/maxmind-geoip/releases/latest/download/City.mmdb")
+	asnDownloadURL  = envOrDefault("IPQUERY_ASN_URL", "https://github.com/xjasonlyu/maxmind-geoip/releases/latest/download/ASN.mmdb")
 )
 
-// 下载文件的通用函数
+// httpClient 走 http.ProxyFromEnvironment，使 HTTPS_PROXY/NO_PROXY 在受限网络下也能生效
+var httpClient = &http.Client{
+	Transport: &http.Transport{Proxy: http.ProxyFromEnvironment},
+}
+
+// 下载文件的通用函数，下载完成后会尝试用 <fileUrl>.sha256 校验完整性
 func downloadFile(filePath string, fileUrl string) error {
-	log.Printf("Downloading %s.\n", filePath)
-	response, err := http.Get(fileUrl)
+	appLogger.Infof("Downloading %s.\n", filePath)
+	response, err := httpClient.Get(fileUrl)
 	if err != nil {
 		return err
 	}
@@ -95,19 +117,60 @@ func downloadFile(filePath string, fileUrl string) error {
 	if err != nil {
 		return err
 	}
-	defer outputFile.Close()
 
-	_, err = io.Copy(outputFile, response.Body)
-	return err
+	hasher := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(outputFile, hasher), response.Body); err != nil {
+		outputFile.Close()
+		return err
+	}
+	if err := outputFile.Close(); err != nil {
+		return err
+	}
+
+	return verifyChecksum(filePath, fileUrl, hasher)
+}
+
+// verifyChecksum 拉取 <fileUrl>.sha256，若存在则校验刚下载文件的摘要是否一致
+// 校验和文件本身不存在（404 等）时视为该数据源未发布校验和，不阻塞下载
+func verifyChecksum(filePath, fileUrl string, hasher interface{ Sum([]byte) []byte }) error {
+	response, err := httpClient.Get(fileUrl + ".sha256")
+	if err != nil {
+		appLogger.Infof("No checksum available for %s, skipping verification: %v\n", fileUrl, err)
+		return nil
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		appLogger.Infof("No checksum published for %s (status %d), skipping verification\n", fileUrl, response.StatusCode)
+		return nil
+	}
+
+	expectedRaw, err := io.ReadAll(response.Body)
+	if err != nil {
+		return fmt.Errorf("reading checksum for %s: %w", fileUrl, err)
+	}
+
+	fields := strings.Fields(string(expectedRaw))
+	if len(fields) == 0 {
+		appLogger.Infof("Empty checksum file for %s, skipping verification\n", fileUrl)
+		return nil
+	}
+	expected := strings.ToLower(fields[0])
+	actual := hex.EncodeToString(hasher.Sum(nil))
+	if expected != actual {
+		_ = os.Remove(filePath)
+		return fmt.Errorf("checksum mismatch for %s: expected %s, got %s", filePath, expected, actual)
+	}
+	return nil
 }
 
 func ensureDatabaseExists(fileName string, fileUrl string) {
 	if _, err := os.Stat(fileName); os.IsNotExist(err) {
-		log.Printf("Database %s not found. Starting download.\n", fileName)
+		appLogger.Infof("Database %s not found. Starting download.\n", fileName)
 		if err := downloadFile(fileName, fileUrl); err != nil {
-			log.Fatalf("Failed to download %s: %v", fileName, err)
+			appLogger.Fatalf("Failed to download %s: %v", fileName, err)
 		}
-		log.Printf("Successfully downloaded %s\n", fileName)
+		appLogger.Infof("Successfully downloaded %s\n", fileName)
 	}
 }
 
@@ -125,80 +188,237 @@ func getCachedTimeLocation(tzName string) (*time.Location, error) {
 	return loc, err
 }
 
-// 热重载更新数据库
-func updateDatabases() {
-	log.Println("Database update begins.")
+// 热重载更新数据库：对 City/ASN 这两个核心数据源走带签名校验、冒烟测试和
+// 备份保留的加固状态机（见 update.go），辅助数据源仍走原有的简单下载替换流程
+// updateCycleInFlight 保证任意时刻只有一个 updateDatabases 周期在跑。
+// 定时任务和 /admin/reload 都走这个唯一入口，并发触发时后来者直接跳过，
+// 而不是让 hardenedUpdate/updateSecondaryDatabases 对相同的 .tmp 路径打架
+var updateCycleInFlight int32
+
+// updateDatabases 依次刷新 City/ASN/辅助数据库；返回 false 表示因为已有
+// 周期在跑而跳过了本次触发，调用方（如 /admin/reload）可据此告知调用者重试
+func updateDatabases() bool {
+	if !atomic.CompareAndSwapInt32(&updateCycleInFlight, 0, 1) {
+		appLogger.Infof("Database update already in progress, skipping this trigger.\n")
+		return false
+	}
+	defer atomic.StoreInt32(&updateCycleInFlight, 0)
 
-	cityTmp := filepath.Join(dbDir, "City.mmdb.tmp")
-	asnTmp := filepath.Join(dbDir, "ASN.mmdb.tmp")
-	cityFinal := filepath.Join(dbDir, "City.mmdb")
-	asnFinal := filepath.Join(dbDir, "ASN.mmdb")
+	appLogger.Infof("Database update begins.")
 
-	// 下载新文件到临时路径
-	if err := downloadFile(cityTmp, "https://github.com/xjasonlyu/maxmind-geoip/releases/latest/download/City.mmdb"); err != nil {
-		log.Printf("Failed to download new City DB: %v\n", err)
-		return
+	cityResult := hardenedUpdateCityDB()
+	postUpdateWebhook(cityResult)
+	metrics.RecordReload("city", cityResult.Result == "success")
+
+	asnResult := hardenedUpdateASNDB()
+	postUpdateWebhook(asnResult)
+	metrics.RecordReload("asn", asnResult.Result == "success")
+
+	updateSecondaryDatabases()
+
+	appLogger.Infof("Database update complete.")
+	return true
+}
+
+// updateSecondaryDatabases 下载并热重载 QQWry/ZXWry/ip2region 这几个辅助数据源
+// 每个数据源都有自己的文件和锁，失败不影响其它数据源或 MaxMind 主库
+func updateSecondaryDatabases() {
+	for _, src := range secondaryDatabaseSources {
+		tmp := filepath.Join(dbDir, src.fileName+".tmp")
+		final := filepath.Join(dbDir, src.fileName)
+
+		if err := downloadFile(tmp, src.url); err != nil {
+			appLogger.Infof("Failed to download new %s: %v\n", src.fileName, err)
+			metrics.RecordReload(src.fileName, false)
+			continue
+		}
+		if err := src.reload(tmp); err != nil {
+			appLogger.Infof("Failed to load newly downloaded %s: %v\n", src.fileName, err)
+			metrics.RecordReload(src.fileName, false)
+			continue
+		}
+		if err := os.Rename(tmp, final); err != nil {
+			appLogger.Infof("Failed to install new %s: %v\n", src.fileName, err)
+			metrics.RecordReload(src.fileName, false)
+			continue
+		}
+		metrics.RecordReload(src.fileName, true)
 	}
-	if err := downloadFile(asnTmp, "https://github.com/xjasonlyu/maxmind-geoip/releases/latest/download/ASN.mmdb"); err != nil {
-		log.Printf("Failed to download new ASN DB: %v\n", err)
-		return
+}
+
+// secondaryDatabaseSource 描述一个非 MaxMind 数据源的下载地址、本地文件名及重载函数
+type secondaryDatabaseSource struct {
+	fileName string
+	url      string
+	reload   func(path string) error
+}
+
+var secondaryDatabaseSources []secondaryDatabaseSource
+
+// 辅助数据源的下载地址，均可通过环境变量覆盖（与 cityDownloadURL/asnDownloadURL 同一约定）
+var (
+	qqwryURL     = envOrDefault("IPQUERY_QQWRY_URL", "https://github.com/metowolf/qqwry.dat/releases/latest/download/qqwry.dat")
+	zxwryURL     = envOrDefault("IPQUERY_ZXWRY_URL", "https://github.com/metowolf/ZXIPv6wry.db/releases/latest/download/ZXIPv6wry.db")
+	ip2regionURL = envOrDefault("IPQUERY_IP2REGION_URL", "https://github.com/lionsoul2014/ip2region/raw/master/data/ip2region.xdb")
+)
+
+// ensureSecondaryDatabaseExists 与 ensureDatabaseExists 相同，但这些数据源都是可选增强：
+// 下载失败只返回 error 由调用方记录日志并跳过该 provider，不会像 City/ASN 那样 appLogger.Fatalf
+func ensureSecondaryDatabaseExists(path, url string) error {
+	if _, err := os.Stat(path); err == nil {
+		return nil
 	}
+	appLogger.Infof("Secondary database %s not found. Starting download.\n", path)
+	if err := downloadFile(path, url); err != nil {
+		return err
+	}
+	appLogger.Infof("Successfully downloaded %s\n", path)
+	return nil
+}
 
-	// 尝试打开新数据库 验证文件完整性和可用性
-	newCityDB, err := maxminddb.Open(cityTmp)
-	if err != nil {
-		log.Printf("Failed to open newly downloaded City DB: %v\n", err)
-		return
+// initProviders 为每个辅助数据源做一次首启动引导下载（如果 dbDir 下还没有文件），
+// 打开对应 provider，并注册热重载来源。每个 provider 的缺失都只是降级
+// （defaultChain 中少一环），不影响服务启动
+func initProviders() {
+	var chain []LookupProvider
+	chain = append(chain, MaxMindProvider{})
+
+	qqwryPath := filepath.Join(dbDir, "qqwry.dat")
+	if err := ensureSecondaryDatabaseExists(qqwryPath, qqwryURL); err != nil {
+		appLogger.Infof("Failed to bootstrap QQWry database: %v\n", err)
 	}
-	newAsnDB, err := maxminddb.Open(asnTmp)
-	if err != nil {
-		log.Printf("Failed to open newly downloaded ASN DB: %v\n", err)
-		newCityDB.Close()
-		return
+	if _, err := os.Stat(qqwryPath); err == nil {
+		provider, err := NewQQWryProvider(qqwryPath)
+		if err != nil {
+			appLogger.Infof("Failed to load QQWry database: %v\n", err)
+		} else {
+			qqwryProvider = provider
+			chain = append(chain, provider)
+			secondaryDatabaseSources = append(secondaryDatabaseSources, secondaryDatabaseSource{
+				fileName: "qqwry.dat",
+				url:      qqwryURL,
+				reload:   provider.reload,
+			})
+		}
+	}
+
+	zxwryPath := filepath.Join(dbDir, "ZXIPv6wry.db")
+	if err := ensureSecondaryDatabaseExists(zxwryPath, zxwryURL); err != nil {
+		appLogger.Infof("Failed to bootstrap ZXIPv6Wry database: %v\n", err)
+	}
+	if _, err := os.Stat(zxwryPath); err == nil {
+		provider, err := NewZXWryProvider(zxwryPath)
+		if err != nil {
+			appLogger.Infof("Failed to load ZXIPv6Wry database: %v\n", err)
+		} else {
+			zxwryProvider = provider
+			chain = append(chain, provider)
+			secondaryDatabaseSources = append(secondaryDatabaseSources, secondaryDatabaseSource{
+				fileName: "ZXIPv6wry.db",
+				url:      zxwryURL,
+				reload:   provider.reload,
+			})
+		}
+	}
+
+	ip2regionPath := filepath.Join(dbDir, "ip2region.xdb")
+	if err := ensureSecondaryDatabaseExists(ip2regionPath, ip2regionURL); err != nil {
+		appLogger.Infof("Failed to bootstrap ip2region database: %v\n", err)
 	}
+	if _, err := os.Stat(ip2regionPath); err == nil {
+		provider, err := NewIP2RegionProvider(ip2regionPath)
+		if err != nil {
+			appLogger.Infof("Failed to load ip2region database: %v\n", err)
+		} else {
+			ip2regionProvider = provider
+			chain = append(chain, provider)
+			secondaryDatabaseSources = append(secondaryDatabaseSources, secondaryDatabaseSource{
+				fileName: "ip2region.xdb",
+				url:      ip2regionURL,
+				reload:   provider.reload,
+			})
+		}
+	}
+
+	defaultChain = NewChainProvider(chain...)
+}
 
-	// 获取写锁
-	databaseMutex.Lock()
-	oldCityDB := cityDatabase
-	oldAsnDB := asnDatabase
-	cityDatabase = newCityDB
-	asnDatabase = newAsnDB
-	databaseMutex.Unlock()
+// updateCronExpr 来自 Config.UpdateCron（-config 的 update_cron 字段），是标准
+// 5 字段 crontab 表达式（分 时 日 月 星期），只支持 "*" 和单个整数，不支持
+// 列表/区间/步长。留空时沿用下面 defaultNextSunday 的周日 0 点兜底调度
+var updateCronExpr string
 
-	// 释放锁安全关闭旧的数据库句柄
-	if oldCityDB != nil {
-		oldCityDB.Close()
+// defaultNextSunday 是 updateCronExpr 未设置时的兜底调度：下个周日 UTC 0:00:00
+func defaultNextSunday(now time.Time) time.Time {
+	daysUntilSunday := int(time.Sunday - now.Weekday())
+	if daysUntilSunday < 0 {
+		daysUntilSunday += 7
 	}
-	if oldAsnDB != nil {
-		oldAsnDB.Close()
+
+	next := time.Date(now.Year(), now.Month(), now.Day()+daysUntilSunday, 0, 0, 0, 0, time.UTC)
+	if next.Before(now) || next.Equal(now) {
+		next = next.AddDate(0, 0, 7)
 	}
+	return next
+}
 
-	_ = os.Rename(cityTmp, cityFinal)
-	_ = os.Rename(asnTmp, asnFinal)
+// cronFieldMatches 把单个 crontab 字段（"*" 或一个整数）与实际值比较
+func cronFieldMatches(field string, value int) bool {
+	if field == "*" {
+		return true
+	}
+	n, err := strconv.Atoi(field)
+	return err == nil && n == value
+}
 
-	log.Println("Database update complete.")
+// cronMatches 检查 t 是否命中标准 5 字段 crontab 表达式（分 时 日 月 星期）
+func cronMatches(expr string, t time.Time) (bool, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return false, fmt.Errorf("update_cron must have 5 fields (minute hour dom month dow), got %d", len(fields))
+	}
+	return cronFieldMatches(fields[0], t.Minute()) &&
+		cronFieldMatches(fields[1], t.Hour()) &&
+		cronFieldMatches(fields[2], t.Day()) &&
+		cronFieldMatches(fields[3], int(t.Month())) &&
+		cronFieldMatches(fields[4], int(t.Weekday())), nil
 }
 
-// 定时任务调度器
+// nextCronRun 从 now 往后逐分钟查找下一个命中 expr 的时间点，最多找一年
+func nextCronRun(expr string, now time.Time) (time.Time, error) {
+	candidate := now.Truncate(time.Minute).Add(time.Minute)
+	for i := 0; i < 366*24*60; i++ {
+		matched, err := cronMatches(expr, candidate)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if matched {
+			return candidate, nil
+		}
+		candidate = candidate.Add(time.Minute)
+	}
+	return time.Time{}, fmt.Errorf("no time within a year matches update_cron %q", expr)
+}
+
+// 定时任务调度器：优先使用 updateCronExpr（config 文件的 update_cron），
+// 解析失败或未设置时回退到 defaultNextSunday 的周日 0 点调度
 func startCronJob() {
 	go func() {
 		for {
 			now := time.Now().UTC()
-			// 计算到下个周日的天数
-			daysUntilSunday := int(time.Sunday - now.Weekday())
-			if daysUntilSunday < 0 {
-				daysUntilSunday += 7
-			}
 
-			// 计算下个周日 UTC 0:00:00 的精准时间
-			nextSunday := time.Date(now.Year(), now.Month(), now.Day()+daysUntilSunday, 0, 0, 0, 0, time.UTC)
-
-			if nextSunday.Before(now) || nextSunday.Equal(now) {
-				nextSunday = nextSunday.AddDate(0, 0, 7)
+			next := defaultNextSunday(now)
+			if updateCronExpr != "" {
+				parsed, err := nextCronRun(updateCronExpr, now)
+				if err != nil {
+					appLogger.Infof("Invalid update_cron %q, falling back to weekly default: %v\n", updateCronExpr, err)
+				} else {
+					next = parsed
+				}
 			}
 
-			sleepDuration := nextSunday.Sub(now)
-			log.Printf("Next database update scheduled in %v (at %v UTC)\n", sleepDuration, nextSunday)
+			sleepDuration := next.Sub(now)
+			appLogger.Infof("Next database update scheduled in %v (at %v UTC)\n", sleepDuration, next)
 
 			time.Sleep(sleepDuration)
 
@@ -210,36 +430,85 @@ func startCronJob() {
 func main() {
 	var err error
 
+	flag.Parse()
+	batchLimit = *batchLimitFlag
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		appLogger.Fatalf("Failed to load config %s: %v", *configPath, err)
+	}
+	applyConfig(cfg)
+
 	// 确保 db 目录存在
 	if err := os.MkdirAll(dbDir, 0755); err != nil {
-		log.Fatalf("Failed to create database directory: %v", err)
+		appLogger.Fatalf("Failed to create database directory: %v", err)
 	}
 
 	cityPath := filepath.Join(dbDir, "City.mmdb")
 	asnPath := filepath.Join(dbDir, "ASN.mmdb")
 
-	ensureDatabaseExists(cityPath, "https://github.com/xjasonlyu/maxmind-geoip/releases/latest/download/City.mmdb")
-	ensureDatabaseExists(asnPath, "https://github.com/xjasonlyu/maxmind-geoip/releases/latest/download/ASN.mmdb")
+	ensureDatabaseExists(cityPath, cityDownloadURL)
+	ensureDatabaseExists(asnPath, asnDownloadURL)
 
 	// 加载数据库
 	cityDatabase, err = maxminddb.Open(cityPath)
 	if err != nil {
-		log.Fatalf("Error opening City.mmdb: %v", err)
+		appLogger.Fatalf("Error opening City.mmdb: %v", err)
 	}
 	defer cityDatabase.Close()
 
 	asnDatabase, err = maxminddb.Open(asnPath)
 	if err != nil {
-		log.Fatalf("Error opening ASN.mmdb: %v", err)
+		appLogger.Fatalf("Error opening ASN.mmdb: %v", err)
 	}
 	defer asnDatabase.Close()
 
+	// 初始化可插拔的查询 provider 链（QQWry/ZXWry/ip2region 为可选项）
+	initProviders()
+
+	// 为 /nearby 预建一份按 ASN 去重的代表性坐标样本
+	buildNearbySamples()
+
 	// 启动后台定时更新任务
 	startCronJob()
 
-	http.HandleFunc("/", ipHandler)
-	log.Println("maxmind query interface is running on port: 8080")
-	log.Fatal(http.ListenAndServe(":8080", nil))
+	http.Handle("/", observability.Middleware(metrics, appLogger, http.HandlerFunc(ipHandler)))
+	http.Handle("/batch", observability.StreamingMiddleware(metrics, appLogger, http.HandlerFunc(batchHandler)))
+	http.Handle("/distance", observability.Middleware(metrics, appLogger, http.HandlerFunc(distanceHandler)))
+	http.Handle("/nearby", observability.Middleware(metrics, appLogger, http.HandlerFunc(nearbyHandler)))
+	http.HandleFunc("/healthz", healthzHandler)
+	http.HandleFunc("/readyz", readyzHandler)
+	http.Handle("/metrics", metrics.Handler())
+	http.HandleFunc("/admin/reload", adminReloadHandler)
+	http.HandleFunc("/admin/rollback", adminRollbackHandler)
+	appLogger.Infof("maxmind query interface is running on %s\n", listenAddr)
+	appLogger.Fatal(http.ListenAndServe(listenAddr, nil))
+}
+
+// resolveLang 把 ?lang=/"lang" 输入的各种别名归一化成 maxminddb names 里用的语言代码
+func resolveLang(queryLang string) string {
+	queryLang = strings.ToLower(queryLang)
+	switch queryLang {
+	case "cn", "zh", "zh-cn", "zh_cn":
+		return "zh-CN"
+	case "pt", "br", "pt-br", "pt_br":
+		return "pt-BR"
+	case "de", "ger":
+		return "de"
+	case "es", "spa":
+		return "es"
+	case "fr", "fre":
+		return "fr"
+	case "ja", "jp", "jpn":
+		return "ja"
+	case "ru", "rus":
+		return "ru"
+	case "en", "eng":
+		return "en"
+	case "":
+		return "en"
+	default:
+		return queryLang
+	}
 }
 
 func ipHandler(writer http.ResponseWriter, request *http.Request) {
@@ -272,32 +541,27 @@ func ipHandler(writer http.ResponseWriter, request *http.Request) {
 	}
 
 	// 获取并解析 ?lang= 参数 默认英文
-	queryLang := strings.ToLower(request.URL.Query().Get("lang"))
-	targetLang := "en"
-	switch queryLang {
-	case "cn", "zh", "zh-cn", "zh_cn":
-		targetLang = "zh-CN"
-	case "pt", "br", "pt-br", "pt_br":
-		targetLang = "pt-BR"
-	case "de", "ger":
-		targetLang = "de"
-	case "es", "spa":
-		targetLang = "es"
-	case "fr", "fre":
-		targetLang = "fr"
-	case "ja", "jp", "jpn":
-		targetLang = "ja"
-	case "ru", "rus":
-		targetLang = "ru"
-	case "en", "eng":
-		targetLang = "en"
-	default:
-		if queryLang != "" {
-			targetLang = queryLang
-		}
+	targetLang := resolveLang(request.URL.Query().Get("lang"))
+
+	// 根据 ?source= 选择查询链，缺省使用启动时构建的 defaultChain
+	provider := buildProviderChain(strings.ToLower(request.URL.Query().Get("source")))
+
+	apiResponse, err := provider.Lookup(ipAddress, targetLang)
+	if err != nil {
+		writer.WriteHeader(http.StatusInternalServerError)
+		_, _ = writer.Write([]byte(`{"error": "lookup failed"}`))
+		return
 	}
+	markLookupSucceeded()
+
+	encoder := json.NewEncoder(writer)
+	encoder.SetIndent("", "  ")
+	_ = encoder.Encode(apiResponse)
+}
 
-	// 辅助提取对应语言
+// flattenMaxMind 把 MaxMind 的 City/ASN 记录铺平成对外的 FlatResponse，
+// names 按 targetLang 取值，取不到时回退英文
+func flattenMaxMind(ip net.IP, cityRecord CityRecord, asnRecord ASNRecord, targetLang string) *FlatResponse {
 	getName := func(names map[string]string) string {
 		if name, exists := names[targetLang]; exists && name != "" {
 			return name
@@ -305,17 +569,8 @@ func ipHandler(writer http.ResponseWriter, request *http.Request) {
 		return names["en"]
 	}
 
-	// 使用共享读锁包围读取操作 保障数据库更新时的内存安全
-	databaseMutex.RLock()
-	var cityRecord CityRecord
-	_ = cityDatabase.Lookup(ipAddress, &cityRecord)
-
-	var asnRecord ASNRecord
-	_ = asnDatabase.Lookup(ipAddress, &asnRecord)
-	databaseMutex.RUnlock()
-
-	apiResponse := FlatResponse{
-		IP: ipAddress.String(),
+	apiResponse := &FlatResponse{
+		IP: ip.String(),
 	}
 
 	// 填充 ASN
@@ -373,7 +628,5 @@ func ipHandler(writer http.ResponseWriter, request *http.Request) {
 		}
 	}
 
-	encoder := json.NewEncoder(writer)
-	encoder.SetIndent("", "  ")
-	_ = encoder.Encode(apiResponse)
+	return apiResponse
 }
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// LookupProvider 是所有 IP 数据库后端的统一接口
+// 每个实现负责打开/持有自己的数据文件句柄，并在 Lookup 中返回扁平化的响应
+type LookupProvider interface {
+	// Name 返回 provider 的唯一标识，用于 ?source= 参数匹配和日志
+	Name() string
+	// Lookup 查询单个 IP，lang 为目标语言（与 ipHandler 中的 targetLang 一致）
+	Lookup(ip net.IP, lang string) (*FlatResponse, error)
+}
+
+// ChainProvider 按配置顺序依次查询多个 provider，并将结果合并为一条记录
+// 合并规则：先到先得——链中靠前的 provider 已经填充的字段不会被后面的覆盖
+type ChainProvider struct {
+	providers []LookupProvider
+}
+
+func NewChainProvider(providers ...LookupProvider) *ChainProvider {
+	return &ChainProvider{providers: providers}
+}
+
+func (c *ChainProvider) Name() string {
+	names := make([]string, 0, len(c.providers))
+	for _, p := range c.providers {
+		names = append(names, p.Name())
+	}
+	return strings.Join(names, "+")
+}
+
+func (c *ChainProvider) Lookup(ip net.IP, lang string) (*FlatResponse, error) {
+	merged := &FlatResponse{IP: ip.String()}
+	var lastErr error
+	queried := 0
+
+	for _, provider := range c.providers {
+		resp, err := provider.Lookup(ip, lang)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		queried++
+		mergeFlatResponse(merged, resp)
+	}
+
+	if queried == 0 && lastErr != nil {
+		return nil, lastErr
+	}
+	return merged, nil
+}
+
+// mergeFlatResponse 把 src 中非空字段填充进 dst 里尚未填充的同名字段
+func mergeFlatResponse(dst, src *FlatResponse) {
+	if dst.ASN == nil {
+		dst.ASN = src.ASN
+	}
+	if dst.Org == "" {
+		dst.Org = src.Org
+	}
+	if dst.ContinentCode == "" && dst.Continent == "" {
+		dst.ContinentCode = src.ContinentCode
+		dst.Continent = src.Continent
+	}
+	if dst.CountryCode == "" && dst.Country == "" {
+		dst.CountryCode = src.CountryCode
+		dst.Country = src.Country
+	}
+	if dst.RegisteredCountryCode == "" && dst.RegisteredCountry == "" {
+		dst.RegisteredCountryCode = src.RegisteredCountryCode
+		dst.RegisteredCountry = src.RegisteredCountry
+	}
+	if dst.RegionCode == "" && dst.Region == "" {
+		dst.RegionCode = src.RegionCode
+		dst.Region = src.Region
+	}
+	if dst.City == "" {
+		dst.City = src.City
+	}
+	if dst.PostalCode == "" {
+		dst.PostalCode = src.PostalCode
+	}
+	if dst.Longitude == nil {
+		dst.Longitude = src.Longitude
+		dst.Latitude = src.Latitude
+	}
+	if dst.AccuracyRadius == nil {
+		dst.AccuracyRadius = src.AccuracyRadius
+	}
+	if dst.Offset == nil {
+		dst.Offset = src.Offset
+	}
+	if dst.TimeZone == "" {
+		dst.TimeZone = src.TimeZone
+	}
+}
+
+// MaxMindProvider 包装现有的 City/ASN mmdb 读取逻辑，实现 LookupProvider
+type MaxMindProvider struct{}
+
+func (MaxMindProvider) Name() string { return "maxmind" }
+
+func (MaxMindProvider) Lookup(ip net.IP, lang string) (*FlatResponse, error) {
+	databaseMutex.RLock()
+	defer databaseMutex.RUnlock()
+
+	if cityDatabase == nil || asnDatabase == nil {
+		return nil, fmt.Errorf("maxmind: database not loaded")
+	}
+
+	var cityRecord CityRecord
+	_ = cityDatabase.Lookup(ip, &cityRecord)
+
+	var asnRecord ASNRecord
+	_ = asnDatabase.Lookup(ip, &asnRecord)
+
+	return flattenMaxMind(ip, cityRecord, asnRecord, lang), nil
+}
+
+// buildProviderChain 根据 ?source= 参数（逗号分隔，如 "maxmind,qqwry"）构造查询链
+// 参数缺省时使用全局默认链 defaultChain
+func buildProviderChain(sourceParam string) LookupProvider {
+	if sourceParam == "" {
+		return defaultChain
+	}
+
+	registry := map[string]LookupProvider{
+		"maxmind":   MaxMindProvider{},
+		"qqwry":     qqwryProvider,
+		"zxwry":     zxwryProvider,
+		"ip2region": ip2regionProvider,
+	}
+
+	var selected []LookupProvider
+	for _, name := range strings.Split(sourceParam, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if provider, ok := registry[name]; ok && provider != nil {
+			selected = append(selected, provider)
+		}
+	}
+
+	if len(selected) == 0 {
+		return defaultChain
+	}
+	return NewChainProvider(selected...)
+}
+
+// defaultChain 与各 provider 实例由 main 在启动时按 dbDir 下可用的数据文件初始化
+var (
+	defaultChain      LookupProvider
+	qqwryProvider     LookupProvider
+	zxwryProvider     LookupProvider
+	ip2regionProvider LookupProvider
+)
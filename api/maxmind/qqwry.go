@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+// QQWry 解析纯真 IP 数据库（qqwry.dat），仅支持 IPv4
+//
+// 文件格式：
+//   - 头部 8 字节：起始索引偏移(4) + 结束索引偏移(4)
+//   - 索引区：每条 7 字节，4 字节起始 IP（小端）+ 3 字节记录偏移（小端）
+//   - 记录区：每条记录由「国家」「地区」两段变长字符串组成，
+//     每段可以是：
+//     0x01 -> 后续 3 字节是绝对偏移，整条记录重定向到该偏移（国家+地区都在那里）
+//     0x02 -> 后续 3 字节是绝对偏移，仅该字段重定向（用于多个 IP 段共享地区文本）
+//     否则 -> 以 0x00 结尾的 GBK 字符串
+type QQWryProvider struct {
+	mu   sync.RWMutex
+	data []byte
+	// indexStart/indexEnd 是索引区在 data 中的绝对偏移
+	indexStart uint32
+	indexEnd   uint32
+}
+
+const (
+	qqwryRedirectFull = 0x01
+	qqwryRedirectArea = 0x02
+)
+
+func NewQQWryProvider(path string) (*QQWryProvider, error) {
+	p := &QQWryProvider{}
+	if err := p.reload(path); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *QQWryProvider) Name() string { return "qqwry" }
+
+// reload 支持热加载：databaseMutex 保护的是 maxmind 句柄，qqwry 自带独立读写锁
+func (p *QQWryProvider) reload(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("qqwry: %w", err)
+	}
+	if len(data) < 8 {
+		return fmt.Errorf("qqwry: file %s too small", path)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.data = data
+	p.indexStart = binary.LittleEndian.Uint32(data[0:4])
+	p.indexEnd = binary.LittleEndian.Uint32(data[4:8])
+	return nil
+}
+
+const qqwryIndexRecordLen = 7
+
+func (p *QQWryProvider) Lookup(ip net.IP, lang string) (*FlatResponse, error) {
+	v4 := ip.To4()
+	if v4 == nil {
+		return nil, fmt.Errorf("qqwry: ipv6 not supported")
+	}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	offset, err := p.findRecordOffset(binary.BigEndian.Uint32(v4))
+	if err != nil {
+		return nil, err
+	}
+
+	country, area, err := p.readCountryArea(offset + 4)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &FlatResponse{IP: ip.String()}
+	resp.Country = country
+	resp.Region = area
+	if country != "" {
+		resp.Org = country
+		if area != "" && area != "CZ88.NET" {
+			resp.Org = country + " " + area
+		}
+	}
+	return resp, nil
+}
+
+// findRecordOffset 在索引区对 targetIP（大端序整数）做二分查找，
+// 返回匹配记录在 data 中的绝对偏移
+func (p *QQWryProvider) findRecordOffset(targetIP uint32) (uint32, error) {
+	recordCount := (p.indexEnd-p.indexStart)/qqwryIndexRecordLen + 1
+
+	low, high := uint32(0), recordCount-1
+	var bestOffset uint32
+	found := false
+
+	for low <= high {
+		mid := low + (high-low)/2
+		entryOffset := p.indexStart + mid*qqwryIndexRecordLen
+		if entryOffset+qqwryIndexRecordLen > uint32(len(p.data)) {
+			break
+		}
+		startIP := binary.LittleEndian.Uint32(p.data[entryOffset : entryOffset+4])
+
+		if startIP <= targetIP {
+			bestOffset = entryOffset
+			found = true
+			low = mid + 1
+		} else {
+			if mid == 0 {
+				break
+			}
+			high = mid - 1
+		}
+	}
+
+	if !found {
+		return 0, fmt.Errorf("qqwry: ip not found in index")
+	}
+
+	recordOffset := uint24(p.data[bestOffset+4 : bestOffset+7])
+	return recordOffset, nil
+}
+
+// readCountryArea 解析记录体中的国家和地区字段，处理 0x01/0x02 重定向
+func (p *QQWryProvider) readCountryArea(offset uint32) (country, area string, err error) {
+	if offset >= uint32(len(p.data)) {
+		return "", "", fmt.Errorf("qqwry: offset out of range")
+	}
+
+	mode := p.data[offset]
+	switch mode {
+	case qqwryRedirectFull:
+		if offset+4 > uint32(len(p.data)) {
+			return "", "", fmt.Errorf("qqwry: truncated redirect at offset %d", offset)
+		}
+		target := uint24(p.data[offset+1 : offset+4])
+		return p.readCountryArea(target)
+	case qqwryRedirectArea:
+		if offset+4 > uint32(len(p.data)) {
+			return "", "", fmt.Errorf("qqwry: truncated redirect at offset %d", offset)
+		}
+		target := uint24(p.data[offset+1 : offset+4])
+		country, err = p.readString(offset + 4)
+		if err != nil {
+			return "", "", err
+		}
+		area, err = p.readArea(target)
+		return country, area, err
+	default:
+		country, err = p.readString(offset)
+		if err != nil {
+			return "", "", err
+		}
+		area, err = p.readArea(offset + uint32(len(gbkBytesUntilNull(p.data[offset:]))) + 1)
+		return country, area, err
+	}
+}
+
+// readArea 解析地区字段，它同样可能是一个 0x02 重定向
+func (p *QQWryProvider) readArea(offset uint32) (string, error) {
+	if offset >= uint32(len(p.data)) {
+		return "", nil
+	}
+	if p.data[offset] == qqwryRedirectArea || p.data[offset] == qqwryRedirectFull {
+		if offset+4 > uint32(len(p.data)) {
+			return "", fmt.Errorf("qqwry: truncated redirect at offset %d", offset)
+		}
+		target := uint24(p.data[offset+1 : offset+4])
+		return p.readString(target)
+	}
+	return p.readString(offset)
+}
+
+func (p *QQWryProvider) readString(offset uint32) (string, error) {
+	if offset >= uint32(len(p.data)) {
+		return "", fmt.Errorf("qqwry: string offset out of range")
+	}
+	raw := gbkBytesUntilNull(p.data[offset:])
+	return gbkToUTF8(raw)
+}
+
+func gbkBytesUntilNull(buf []byte) []byte {
+	idx := bytes.IndexByte(buf, 0x00)
+	if idx < 0 {
+		return buf
+	}
+	return buf[:idx]
+}
+
+func gbkToUTF8(gbk []byte) (string, error) {
+	decoded, err := simplifiedchinese.GBK.NewDecoder().Bytes(gbk)
+	if err != nil {
+		return "", fmt.Errorf("qqwry: gbk decode: %w", err)
+	}
+	return string(decoded), nil
+}
+
+func uint24(b []byte) uint32 {
+	return uint32(b[0]) | uint32(b[1])<<8 | uint32(b[2])<<16
+}
@@ -0,0 +1,309 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// updatePublicKeyHex is the hex-encoded Ed25519 public key used to verify
+// <url>.sig signatures before a downloaded database is trusted. Empty disables
+// signature verification (logged once per process, not per update cycle)
+var updatePublicKeyHex = os.Getenv("IPQUERY_UPDATE_PUBKEY")
+
+var unsignedUpdateNotice sync.Once
+
+// updateResult mirrors the payload posted to IPQUERY_UPDATE_WEBHOOK on completion
+type updateResult struct {
+	DB            string `json:"db"`
+	OldBuildEpoch int64  `json:"old_build_epoch"`
+	NewBuildEpoch int64  `json:"new_build_epoch"`
+	Result        string `json:"result"`
+	Error         string `json:"error,omitempty"`
+}
+
+// smokeTestIP pairs a well-known IP with its compiled-in expected country code,
+// used to catch a corrupt-but-openable City DB before it takes traffic
+type smokeTestIP struct {
+	IP          string
+	CountryCode string
+}
+
+var citySmokeTestIPs = []smokeTestIP{
+	{IP: "8.8.8.8", CountryCode: "US"},
+	{IP: "1.1.1.1", CountryCode: "AU"},
+}
+
+func cityUpdateSpec() hardenedUpdateSpec {
+	return hardenedUpdateSpec{
+		name:      "city",
+		url:       cityDownloadURL,
+		finalPath: filepath.Join(dbDir, "City.mmdb"),
+		tmpPath:   filepath.Join(dbDir, "City.mmdb.tmp"),
+		bakPath:   filepath.Join(dbDir, "City.mmdb.bak"),
+		getCurrent: func() *maxminddb.Reader {
+			return cityDatabase
+		},
+		setCurrent: func(r *maxminddb.Reader) {
+			cityDatabase = r
+		},
+		smokeTest: smokeTestCityDB,
+	}
+}
+
+func asnUpdateSpec() hardenedUpdateSpec {
+	return hardenedUpdateSpec{
+		name:      "asn",
+		url:       asnDownloadURL,
+		finalPath: filepath.Join(dbDir, "ASN.mmdb"),
+		tmpPath:   filepath.Join(dbDir, "ASN.mmdb.tmp"),
+		bakPath:   filepath.Join(dbDir, "ASN.mmdb.bak"),
+		getCurrent: func() *maxminddb.Reader {
+			return asnDatabase
+		},
+		setCurrent: func(r *maxminddb.Reader) {
+			asnDatabase = r
+		},
+		smokeTest: smokeTestASNDB,
+	}
+}
+
+// hardenedUpdateCityDB runs the full download -> verify -> smoke-test -> swap -> backup
+// state machine for the City DB
+func hardenedUpdateCityDB() updateResult {
+	return hardenedUpdate(cityUpdateSpec())
+}
+
+// hardenedUpdateASNDB runs the same state machine for the ASN DB; its smoke test
+// only checks that the well-known IPs resolve to a non-zero ASN, since the exact
+// AS number/org text changes too often to compile in
+func hardenedUpdateASNDB() updateResult {
+	return hardenedUpdate(asnUpdateSpec())
+}
+
+// updateMutexes hold one lock per database name so a hardenedUpdate/rollbackDB
+// cycle for "city" can never overlap another cycle for "city" — whether the
+// second trigger came from startCronJob or a concurrent /admin/reload or
+// /admin/rollback call. Without this, two overlapping cycles would download
+// into the same .tmp path, read/close the same getCurrent() pointer twice,
+// and race on the finalPath/bakPath renames
+var updateMutexes = map[string]*sync.Mutex{
+	"city": {},
+	"asn":  {},
+}
+
+// hardenedUpdateSpec parameterizes hardenedUpdate over the City and ASN databases,
+// which share everything but the concrete reader field and smoke test
+type hardenedUpdateSpec struct {
+	name       string
+	url        string
+	finalPath  string
+	tmpPath    string
+	bakPath    string
+	getCurrent func() *maxminddb.Reader
+	setCurrent func(*maxminddb.Reader)
+	smokeTest  func(*maxminddb.Reader) error
+}
+
+// hardenedUpdate implements: (1) download to .tmp, (2) verify checksum (in
+// downloadFile) + signature, (3) open + smoke-test, (4) atomic swap under
+// databaseMutex, (5) keep the previous file as .bak for one cycle
+func hardenedUpdate(spec hardenedUpdateSpec) updateResult {
+	mu := updateMutexes[spec.name]
+	if mu != nil {
+		if !mu.TryLock() {
+			return updateResult{DB: spec.name, Result: "failure", Error: "update already in progress for this database"}
+		}
+		defer mu.Unlock()
+	}
+
+	old := spec.getCurrent()
+	result := updateResult{DB: spec.name, Result: "failure"}
+	if old != nil {
+		result.OldBuildEpoch = int64(old.Metadata.BuildEpoch)
+	}
+
+	if err := downloadFile(spec.tmpPath, spec.url); err != nil {
+		result.Error = fmt.Sprintf("download: %v", err)
+		return result
+	}
+
+	if err := verifySignature(spec.tmpPath, spec.url); err != nil {
+		_ = os.Remove(spec.tmpPath)
+		result.Error = fmt.Sprintf("signature: %v", err)
+		return result
+	}
+
+	newReader, err := maxminddb.Open(spec.tmpPath)
+	if err != nil {
+		_ = os.Remove(spec.tmpPath)
+		result.Error = fmt.Sprintf("open: %v", err)
+		return result
+	}
+
+	if err := spec.smokeTest(newReader); err != nil {
+		newReader.Close()
+		_ = os.Remove(spec.tmpPath)
+		result.Error = fmt.Sprintf("smoke test: %v", err)
+		return result
+	}
+
+	result.NewBuildEpoch = int64(newReader.Metadata.BuildEpoch)
+
+	databaseMutex.Lock()
+	spec.setCurrent(newReader)
+	databaseMutex.Unlock()
+
+	if old != nil {
+		old.Close()
+		_ = os.Remove(spec.bakPath)
+		_ = os.Rename(spec.finalPath, spec.bakPath)
+	}
+	if err := os.Rename(spec.tmpPath, spec.finalPath); err != nil {
+		result.Error = fmt.Sprintf("install: %v", err)
+		return result
+	}
+
+	result.Result = "success"
+	return result
+}
+
+// rollbackDB reopens <db>.mmdb.bak (written by the previous successful hardenedUpdate
+// cycle) and swaps it back in under databaseMutex
+func rollbackDB(spec hardenedUpdateSpec) error {
+	mu := updateMutexes[spec.name]
+	if mu != nil {
+		if !mu.TryLock() {
+			return fmt.Errorf("update already in progress for this database, try again shortly")
+		}
+		defer mu.Unlock()
+	}
+
+	if _, err := os.Stat(spec.bakPath); err != nil {
+		return fmt.Errorf("no backup available: %w", err)
+	}
+
+	backupReader, err := maxminddb.Open(spec.bakPath)
+	if err != nil {
+		return fmt.Errorf("opening backup: %w", err)
+	}
+
+	databaseMutex.Lock()
+	old := spec.getCurrent()
+	spec.setCurrent(backupReader)
+	databaseMutex.Unlock()
+
+	if old != nil {
+		old.Close()
+	}
+	return nil
+}
+
+func smokeTestCityDB(reader *maxminddb.Reader) error {
+	for _, expected := range citySmokeTestIPs {
+		var record CityRecord
+		if err := reader.Lookup(net.ParseIP(expected.IP), &record); err != nil {
+			return fmt.Errorf("lookup %s: %w", expected.IP, err)
+		}
+		if record.Country.IsoCode != expected.CountryCode {
+			return fmt.Errorf("expected %s to resolve to %s, got %q", expected.IP, expected.CountryCode, record.Country.IsoCode)
+		}
+	}
+	return nil
+}
+
+func smokeTestASNDB(reader *maxminddb.Reader) error {
+	for _, expected := range citySmokeTestIPs {
+		var record ASNRecord
+		if err := reader.Lookup(net.ParseIP(expected.IP), &record); err != nil {
+			return fmt.Errorf("lookup %s: %w", expected.IP, err)
+		}
+		if record.AutonomousSystemNumber == 0 {
+			return fmt.Errorf("expected %s to resolve to a known ASN, got none", expected.IP)
+		}
+	}
+	return nil
+}
+
+// verifySignature fetches <url>.sig and checks it against the downloaded file using
+// updatePublicKeyHex. An unset public key is treated as "unsigned source" and the
+// file is allowed through; once a key is set, a missing, malformed or non-matching
+// .sig fails the update, so this only hardens deployments that opt in
+func verifySignature(filePath, url string) error {
+	if updatePublicKeyHex == "" {
+		unsignedUpdateNotice.Do(func() {
+			appLogger.Infof("IPQUERY_UPDATE_PUBKEY not set, skipping signature verification of database updates\n")
+		})
+		return nil
+	}
+
+	pubKeyBytes, err := hex.DecodeString(updatePublicKeyHex)
+	if err != nil || len(pubKeyBytes) != ed25519.PublicKeySize {
+		return fmt.Errorf("invalid IPQUERY_UPDATE_PUBKEY")
+	}
+
+	response, err := httpClient.Get(url + ".sig")
+	if err != nil {
+		return fmt.Errorf("fetching signature: %w", err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("no signature published (status %d)", response.StatusCode)
+	}
+
+	sigHex, err := io.ReadAll(response.Body)
+	if err != nil {
+		return fmt.Errorf("reading signature: %w", err)
+	}
+	signature, err := hex.DecodeString(strings.TrimSpace(string(sigHex)))
+	if err != nil || len(signature) != ed25519.SignatureSize {
+		return fmt.Errorf("malformed signature")
+	}
+
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return fmt.Errorf("reading downloaded file: %w", err)
+	}
+
+	if !ed25519.Verify(ed25519.PublicKey(pubKeyBytes), data, signature) {
+		return fmt.Errorf("signature verification failed")
+	}
+	return nil
+}
+
+// postUpdateWebhook notifies IPQUERY_UPDATE_WEBHOOK (if configured) about the
+// outcome of a hardenedUpdate cycle. Best-effort: failures are logged, not retried
+func postUpdateWebhook(result updateResult) {
+	if updateWebhookURL == "" {
+		return
+	}
+
+	body, err := json.Marshal(result)
+	if err != nil {
+		appLogger.Infof("Failed to marshal update webhook payload: %v\n", err)
+		return
+	}
+
+	response, err := httpClient.Post(updateWebhookURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		appLogger.Infof("Failed to deliver update webhook: %v\n", err)
+		return
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		appLogger.Infof("Update webhook returned status %d\n", response.StatusCode)
+	}
+}
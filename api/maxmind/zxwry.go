@@ -0,0 +1,183 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+// ZXWryProvider 解析纯真 IPv6 数据库（ZXIPv6wry.db），用于弥补 MaxMind
+// 对国内 IPv6 地址段归属地识别不准的问题
+//
+// 文件结构与 QQWry 类似但索引按 128bit 地址的高 64 位排序：
+//   - 头部：4..8 字节为索引区起始偏移，8..12 字节为索引条目数（均为大端），索引区结束位置由二者算出
+//   - 索引区：每条 20 字节，8 字节起始地址高位（大端）+ 4 字节记录偏移 + 4 字节结束地址高位（大端） + 4 字节 保留
+//   - 记录区：与 QQWry 相同的 GBK 变长字符串 + 0x01/0x02 重定向语义
+type ZXWryProvider struct {
+	mu         sync.RWMutex
+	data       []byte
+	indexStart uint32
+	indexEnd   uint32
+	count      uint32
+}
+
+const zxwryIndexRecordLen = 20
+
+func NewZXWryProvider(path string) (*ZXWryProvider, error) {
+	p := &ZXWryProvider{}
+	if err := p.reload(path); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *ZXWryProvider) Name() string { return "zxwry" }
+
+func (p *ZXWryProvider) reload(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("zxwry: %w", err)
+	}
+	if len(data) < 16 {
+		return fmt.Errorf("zxwry: file %s too small", path)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.data = data
+	p.indexStart = binary.BigEndian.Uint32(data[4:8])
+	p.count = binary.BigEndian.Uint32(data[8:12])
+	p.indexEnd = p.indexStart + p.count*zxwryIndexRecordLen
+	return nil
+}
+
+func (p *ZXWryProvider) Lookup(ip net.IP, lang string) (*FlatResponse, error) {
+	v6 := ip.To16()
+	if v6 == nil || ip.To4() != nil {
+		return nil, fmt.Errorf("zxwry: not an ipv6 address")
+	}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	// 只用高 64 位做索引，与纯真 IPv6 库的分配粒度一致
+	highBits := binary.BigEndian.Uint64(v6[0:8])
+
+	recordOffset, err := p.findRecordOffset(highBits)
+	if err != nil {
+		return nil, err
+	}
+
+	country, area, err := p.readCountryArea(recordOffset)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &FlatResponse{IP: ip.String()}
+	resp.Country = country
+	resp.Region = area
+	if country != "" {
+		resp.Org = country
+		if area != "" {
+			resp.Org = country + " " + area
+		}
+	}
+	return resp, nil
+}
+
+func (p *ZXWryProvider) findRecordOffset(targetHigh uint64) (uint32, error) {
+	if p.count == 0 {
+		return 0, fmt.Errorf("zxwry: empty index")
+	}
+
+	low, high := uint32(0), p.count-1
+	var best uint32
+	found := false
+
+	for low <= high {
+		mid := low + (high-low)/2
+		entry := p.indexStart + mid*zxwryIndexRecordLen
+		if entry+zxwryIndexRecordLen > uint32(len(p.data)) {
+			break
+		}
+		startHigh := binary.BigEndian.Uint64(p.data[entry : entry+8])
+
+		if startHigh <= targetHigh {
+			best = entry
+			found = true
+			low = mid + 1
+		} else {
+			if mid == 0 {
+				break
+			}
+			high = mid - 1
+		}
+	}
+
+	if !found {
+		return 0, fmt.Errorf("zxwry: address not found in index")
+	}
+
+	recordOffset := binary.BigEndian.Uint32(p.data[best+8 : best+12])
+	return recordOffset, nil
+}
+
+// readCountryArea 复用 QQWry 的重定向语义（0x01 整体重定向，0x02 仅地区重定向）
+func (p *ZXWryProvider) readCountryArea(offset uint32) (country, area string, err error) {
+	if offset >= uint32(len(p.data)) {
+		return "", "", fmt.Errorf("zxwry: offset out of range")
+	}
+
+	mode := p.data[offset]
+	switch mode {
+	case qqwryRedirectFull:
+		if offset+4 > uint32(len(p.data)) {
+			return "", "", fmt.Errorf("zxwry: truncated redirect at offset %d", offset)
+		}
+		target := uint24(p.data[offset+1 : offset+4])
+		return p.readCountryArea(target)
+	case qqwryRedirectArea:
+		if offset+4 > uint32(len(p.data)) {
+			return "", "", fmt.Errorf("zxwry: truncated redirect at offset %d", offset)
+		}
+		target := uint24(p.data[offset+1 : offset+4])
+		country, err = p.readString(offset + 4)
+		if err != nil {
+			return "", "", err
+		}
+		area, err = p.readString(target)
+		return country, area, err
+	default:
+		raw := gbkBytesUntilNull(p.data[offset:])
+		country, err = gbkToUTF8(raw)
+		if err != nil {
+			return "", "", err
+		}
+		areaOffset := offset + uint32(len(raw)) + 1
+		area, err = p.readString(areaOffset)
+		return country, area, err
+	}
+}
+
+func (p *ZXWryProvider) readString(offset uint32) (string, error) {
+	if offset >= uint32(len(p.data)) {
+		return "", nil
+	}
+	if p.data[offset] == qqwryRedirectFull || p.data[offset] == qqwryRedirectArea {
+		if offset+4 > uint32(len(p.data)) {
+			return "", fmt.Errorf("zxwry: truncated redirect at offset %d", offset)
+		}
+		target := uint24(p.data[offset+1 : offset+4])
+		return p.readString(target)
+	}
+	raw := gbkBytesUntilNull(p.data[offset:])
+	decoded, err := simplifiedchinese.GBK.NewDecoder().Bytes(raw)
+	if err != nil {
+		return "", fmt.Errorf("zxwry: gbk decode: %w", err)
+	}
+	return string(decoded), nil
+}
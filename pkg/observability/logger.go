@@ -0,0 +1,59 @@
+// Package observability provides the structured logging, request metrics
+// and metrics middleware shared by ipquery's HTTP handlers.
+package observability
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+)
+
+// Logger is a thin printf-style adapter over slog so existing call sites
+// ("log.Printf(format, args...)") can switch to structured JSON output
+// with a one-line import change instead of rewriting every log call.
+type Logger struct {
+	*slog.Logger
+}
+
+// NewLogger builds a Logger that emits JSON lines to stdout at the given level
+// ("debug", "info", "warn", "error"; unknown values fall back to "info").
+func NewLogger(level string) *Logger {
+	handler := slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: parseLevel(level)})
+	return &Logger{slog.New(handler)}
+}
+
+func parseLevel(level string) slog.Level {
+	switch level {
+	case "debug":
+		return slog.LevelDebug
+	case "warn":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// WithTraceID returns a child logger that attaches trace_id to every record.
+func (l *Logger) WithTraceID(traceID string) *Logger {
+	return &Logger{l.Logger.With("trace_id", traceID)}
+}
+
+func (l *Logger) Infof(format string, args ...any) {
+	l.Logger.Info(fmt.Sprintf(format, args...))
+}
+
+func (l *Logger) Warnf(format string, args ...any) {
+	l.Logger.Warn(fmt.Sprintf(format, args...))
+}
+
+func (l *Logger) Fatalf(format string, args ...any) {
+	l.Logger.Error(fmt.Sprintf(format, args...))
+	os.Exit(1)
+}
+
+func (l *Logger) Fatal(args ...any) {
+	l.Logger.Error(fmt.Sprint(args...))
+	os.Exit(1)
+}
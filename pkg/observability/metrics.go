@@ -0,0 +1,102 @@
+package observability
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Metrics holds the Prometheus collectors exposed on /metrics.
+type Metrics struct {
+	LookupTotal    *prometheus.CounterVec
+	LookupDuration *prometheus.HistogramVec
+	DBReloadTotal  *prometheus.CounterVec
+	DBAgeSeconds   *dbAgeCollector
+}
+
+// dbAgeCollector exposes ipquery_db_age_seconds as a computed value — seconds
+// elapsed since each database's last successful reload — rather than a static
+// gauge that only ever gets Set(0) on success and then goes stale. There's no
+// labeled equivalent of prometheus.NewGaugeFunc, so this implements
+// prometheus.Collector directly and computes time.Since(lastSuccess[db]) on
+// every scrape.
+type dbAgeCollector struct {
+	desc *prometheus.Desc
+
+	mu          sync.Mutex
+	lastSuccess map[string]time.Time
+}
+
+func newDBAgeCollector() *dbAgeCollector {
+	return &dbAgeCollector{
+		desc: prometheus.NewDesc(
+			"ipquery_db_age_seconds",
+			"Seconds since the last successful reload of each database.",
+			[]string{"db"}, nil,
+		),
+		lastSuccess: make(map[string]time.Time),
+	}
+}
+
+func (c *dbAgeCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *dbAgeCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for db, t := range c.lastSuccess {
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, time.Since(t).Seconds(), db)
+	}
+}
+
+// markSuccess records db's last successful reload as now, resetting its age to 0
+func (c *dbAgeCollector) markSuccess(db string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lastSuccess[db] = time.Now()
+}
+
+// NewMetrics registers ipquery's collectors against the default registerer
+// and returns the handle used to update them from the HTTP layer.
+func NewMetrics() *Metrics {
+	m := &Metrics{
+		LookupTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "ipquery_lookup_total",
+			Help: "Total number of IP lookups served, labeled by source chain, resolved country, and HTTP status.",
+		}, []string{"source", "country", "status"}),
+		LookupDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "ipquery_lookup_duration_seconds",
+			Help:    "Latency of IP lookup requests in seconds, labeled by source chain.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"source"}),
+		DBReloadTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "ipquery_db_reload_total",
+			Help: "Total number of database hot-reload attempts, labeled by db name and result (success/failure).",
+		}, []string{"db", "result"}),
+		DBAgeSeconds: newDBAgeCollector(),
+	}
+
+	prometheus.MustRegister(m.LookupTotal, m.LookupDuration, m.DBReloadTotal, m.DBAgeSeconds)
+	return m
+}
+
+// Handler exposes the registered collectors for Prometheus scraping.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// RecordReload updates DBReloadTotal/DBAgeSeconds after an update attempt.
+func (m *Metrics) RecordReload(db string, success bool) {
+	result := "success"
+	if !success {
+		result = "failure"
+	}
+	m.DBReloadTotal.WithLabelValues(db, result).Inc()
+	if success {
+		m.DBAgeSeconds.markSuccess(db)
+	}
+}
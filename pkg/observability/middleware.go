@@ -0,0 +1,149 @@
+package observability
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Middleware wraps an ipquery HTTP handler with request metrics and
+// structured, trace-id-tagged access logging. It buffers the handler's
+// response so it can pull out the resolved country_code for the
+// ipquery_lookup_total label without the handler needing to know about metrics.
+func Middleware(metrics *Metrics, logger *Logger, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceID := newTraceID()
+		start := time.Now()
+
+		rec := &responseRecorder{ResponseWriter: w, status: http.StatusOK, body: &bytes.Buffer{}}
+		next.ServeHTTP(rec, r)
+		duration := time.Since(start)
+
+		source := r.URL.Query().Get("source")
+		if source == "" {
+			source = "default"
+		}
+		country := extractCountryCode(rec.body.Bytes())
+		status := strconv.Itoa(rec.status)
+
+		metrics.LookupTotal.WithLabelValues(source, country, status).Inc()
+		metrics.LookupDuration.WithLabelValues(source).Observe(duration.Seconds())
+
+		logger.WithTraceID(traceID).Info("request served",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", rec.status,
+			"duration_ms", duration.Milliseconds(),
+			"country", country,
+		)
+
+		w.Header().Set("X-Trace-Id", traceID)
+		w.WriteHeader(rec.status)
+		_, _ = w.Write(rec.body.Bytes())
+	})
+}
+
+type responseRecorder struct {
+	http.ResponseWriter
+	status      int
+	body        *bytes.Buffer
+	wroteHeader bool
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	r.status = status
+	r.wroteHeader = true
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	if !r.wroteHeader {
+		r.status = http.StatusOK
+	}
+	return r.body.Write(b)
+}
+
+func extractCountryCode(body []byte) string {
+	var parsed struct {
+		CountryCode string `json:"country_code"`
+	}
+	if err := json.Unmarshal(body, &parsed); err != nil || parsed.CountryCode == "" {
+		return "unknown"
+	}
+	return parsed.CountryCode
+}
+
+// StreamingMiddleware is Middleware's counterpart for handlers that write
+// their response incrementally (e.g. /batch streaming a JSON array via
+// json.Encoder + http.Flusher). It never buffers the body, so it can't
+// extract a country_code label — the lookup_total metric is recorded with
+// country="batch" instead. Writes and flushes pass straight through to the
+// underlying ResponseWriter as the handler produces them.
+func StreamingMiddleware(metrics *Metrics, logger *Logger, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceID := newTraceID()
+		start := time.Now()
+		w.Header().Set("X-Trace-Id", traceID)
+
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+		duration := time.Since(start)
+
+		source := r.URL.Query().Get("source")
+		if source == "" {
+			source = "default"
+		}
+		status := strconv.Itoa(rec.status)
+
+		metrics.LookupTotal.WithLabelValues(source, "batch", status).Inc()
+		metrics.LookupDuration.WithLabelValues(source).Observe(duration.Seconds())
+
+		logger.WithTraceID(traceID).Info("request served",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", rec.status,
+			"duration_ms", duration.Milliseconds(),
+		)
+	})
+}
+
+// statusRecorder passes writes and flushes straight through to the wrapped
+// ResponseWriter, only intercepting the status code for logging/metrics
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if r.wroteHeader {
+		return
+	}
+	r.status = status
+	r.wroteHeader = true
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if !r.wroteHeader {
+		r.WriteHeader(http.StatusOK)
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+func (r *statusRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func newTraceID() string {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return "00000000"
+	}
+	return hex.EncodeToString(buf)
+}